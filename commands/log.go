@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wallix/awless/template"
+)
+
+var logFormatFlag string
+
+func init() {
+	logCmd.Flags().StringVar(&logFormatFlag, "log-format", "default", `comma-separated list of printer format[:destination] pairs, e.g. "short,json:run.log,junit-xml:report.xml"`)
+	RootCmd.AddCommand(logCmd)
+}
+
+var logCmd = &cobra.Command{
+	Use:   "log TEMPLATE_EXECUTION_FILE",
+	Short: "Print a recorded template execution through one or more log printers",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("log: %s", err)
+		}
+		defer f.Close()
+
+		var t template.TemplateExecution
+		if err := json.NewDecoder(f).Decode(&t); err != nil {
+			return fmt.Errorf("log: %s", err)
+		}
+
+		multi, err := newLogPrinters(logFormatFlag)
+		if err != nil {
+			return err
+		}
+		defer multi.Close()
+
+		return multi.print(&t)
+	},
+}
@@ -2,50 +2,322 @@ package commands
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
+	"os"
+	"regexp"
 	"sort"
 	"strings"
+	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/wallix/awless/console"
 	"github.com/wallix/awless/template"
+	templatelog "github.com/wallix/awless/template/log"
 )
 
 type logPrinter interface {
 	print(*template.TemplateExecution) error
 }
 
+// PrinterFactory builds a logPrinter writing to w for a given format name,
+// e.g. "short", "full", "json", "id".
+type PrinterFactory func(w io.Writer) logPrinter
+
+var printerFactories = map[string]PrinterFactory{
+	"default": newDefaultTemplatePrinter,
+	"short":   func(w io.Writer) logPrinter { return &shortLogPrinter{w} },
+	"full":    func(w io.Writer) logPrinter { return &fullLogPrinter{w: w} },
+	"stat":    func(w io.Writer) logPrinter { return &statLogPrinter{w} },
+	"json":    func(w io.Writer) logPrinter { return &rawJSONPrinter{w} },
+	"id":      func(w io.Writer) logPrinter { return &idOnlyPrinter{w} },
+
+	"junit-xml":    func(w io.Writer) logPrinter { return &junitXMLPrinter{w} },
+	"code-climate": func(w io.Writer) logPrinter { return &codeClimatePrinter{w} },
+	"progress":     newProgressPrinter,
+	"tree":         newTreePrinter,
+}
+
+// MultiPrinter fans out a single TemplateExecution to every configured
+// logPrinter, aggregating any errors they return.
+type MultiPrinter struct {
+	printers []logPrinter
+	closers  []io.Closer
+}
+
+func (p *MultiPrinter) print(t *template.TemplateExecution) error {
+	var errs []string
+	for _, printer := range p.printers {
+		if err := printer.print(t); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("multi printer: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Close releases any file destinations opened for this MultiPrinter.
+func (p *MultiPrinter) Close() error {
+	var errs []string
+	for _, c := range p.closers {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("multi printer: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// newLogPrinters parses a `--log-format` spec such as
+// `short,json:run.log,junit-xml:report.xml` into a MultiPrinter. Each
+// comma-separated entry is `format[:destination]`, where destination is
+// `stdout`, `stderr` or a filepath, defaulting to stdout when omitted.
+func newLogPrinters(spec string) (*MultiPrinter, error) {
+	multi := &MultiPrinter{}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		format := entry
+		dest := "stdout"
+		if idx := strings.Index(entry, ":"); idx >= 0 {
+			format = entry[:idx]
+			dest = entry[idx+1:]
+		}
+
+		factory, ok := printerFactories[format]
+		if !ok {
+			return nil, fmt.Errorf("log printer: unknown format %q", format)
+		}
+
+		w, closer, err := resolvePrinterDestination(dest)
+		if err != nil {
+			return nil, fmt.Errorf("log printer: %s", err)
+		}
+		if closer != nil {
+			multi.closers = append(multi.closers, closer)
+		}
+
+		multi.printers = append(multi.printers, factory(w))
+	}
+
+	return multi, nil
+}
+
+// resolvePrinterDestination resolves a destination name to a writer. The
+// returned io.Closer is nil for stdout/stderr, since those belong to the
+// process, not to this MultiPrinter, and must not be closed by it.
+func resolvePrinterDestination(dest string) (io.Writer, io.Closer, error) {
+	switch dest {
+	case "stdout":
+		return os.Stdout, nil, nil
+	case "stderr":
+		return os.Stderr, nil, nil
+	default:
+		f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open %q: %s", dest, err)
+		}
+		return f, f, nil
+	}
+}
+
 type fullLogPrinter struct {
-	w io.Writer
+	w     io.Writer
+	hooks []templatelog.Hook
+}
+
+// AddHook registers a templatelog.Hook that receives an Event for every
+// command in the template, alongside the terminal output.
+func (p *fullLogPrinter) AddHook(h templatelog.Hook) {
+	p.hooks = append(p.hooks, h)
 }
 
 func (p *fullLogPrinter) print(t *template.TemplateExecution) error {
-	writeSimpleLogHeader(t, p.w)
+	tabw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+
+	writeSimpleLogHeader(t, tabw)
+
+	bus := templatelog.NewBus(append([]templatelog.Hook{&fullLogTerminalHook{tabw}}, p.hooks...)...)
 
 	for _, cmd := range t.CommandNodesIterator() {
-		var status string
-		if cmd.CmdErr != nil {
-			status = renderRedFn("KO")
-		} else {
-			status = renderGreenFn("OK")
+		event := eventForCommand(t, cmd.Action, cmd.Entity, cmd.Err())
+		event.Fields = map[string]interface{}{"line": cmd.String(), "result": cmd.CmdResult}
+		if err := bus.Emit(event); err != nil {
+			return err
 		}
+	}
+	return tabw.Flush()
+}
 
-		var line string
-		if v, ok := cmd.CmdResult.(string); ok && v != "" {
-			line = fmt.Sprintf("    %s\t%s\t[%s]", status, cmd.String(), v)
-		} else {
-			line = fmt.Sprintf("    %s\t%s", status, cmd.String())
-		}
+type fullLogTerminalHook struct {
+	w io.Writer
+}
+
+func (h *fullLogTerminalHook) Fire(e templatelog.Event) error {
+	var status string
+	if e.Level >= templatelog.Error {
+		status = renderRedFn("KO")
+	} else {
+		status = renderGreenFn("OK")
+	}
+	status = padVisibleWidth(status, 2)
+
+	var line string
+	if v, ok := e.Fields["result"].(string); ok && v != "" {
+		line = fmt.Sprintf("    %s\t%v\t[%s]", status, e.Fields["line"], v)
+	} else {
+		line = fmt.Sprintf("    %s\t%v", status, e.Fields["line"])
+	}
 
+	fmt.Fprintln(h.w, line)
+	writeError(e.Err, h.w)
+	return nil
+}
+
+func newProgressPrinter(w io.Writer) logPrinter {
+	return &progressPrinter{w: w}
+}
+
+// ProgressReporter is the extension point an executor would use to drive a
+// printer live, with per-command start/finish callbacks, instead of it only
+// seeing a completed TemplateExecution. Nothing in this package calls it yet:
+// no executor lives in this tree, so progressPrinter only exercises it from
+// its own print() replay below, which still renders after the fact. Wiring
+// Start/CommandStarted/CommandFinished into the real executor's command loop
+// is what would make output appear as each command runs instead of at the end.
+type ProgressReporter interface {
+	Start(total int)
+	CommandStarted(action, entity string)
+	CommandFinished(action, entity string, err error)
+}
+
+// progressPrinter renders a status list and summary bar, overwriting the
+// previous frame in place on a terminal, via the ProgressReporter methods.
+// print() implements logPrinter by replaying an already-completed
+// TemplateExecution through those same methods, so today it only ever
+// renders once the template has finished running — see ProgressReporter's
+// doc comment for what's missing to make it live.
+type progressPrinter struct {
+	w io.Writer
+
+	mu       sync.Mutex
+	total    int
+	finished int
+	lines    []string
+	rendered int
+}
+
+func (p *progressPrinter) Start(total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total = total
+}
+
+func (p *progressPrinter) CommandStarted(action, entity string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lines = append(p.lines, fmt.Sprintf("[%d/%d] %s %s ...", len(p.lines)+1, p.total, action, entity))
+	p.render()
+}
+
+func (p *progressPrinter) CommandFinished(action, entity string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := renderGreenFn("OK")
+	if err != nil {
+		status = renderRedFn("KO")
+	}
+	if n := len(p.lines); n > 0 {
+		p.lines[n-1] = fmt.Sprintf("[%d/%d] %s %s ... %s", n, p.total, action, entity, status)
+	}
+	p.finished++
+	p.render()
+}
+
+// render rewrites the full frame, assuming p.mu is held.
+func (p *progressPrinter) render() {
+	if isTerminal(p.w) && p.rendered > 0 {
+		clearPreviousLines(p.w, p.rendered)
+	}
+
+	for _, line := range p.lines {
 		fmt.Fprintln(p.w, line)
+	}
+	fmt.Fprintln(p.w, progressBar(p.finished, p.total))
+	p.rendered = len(p.lines) + 1
+}
 
-		writeError(cmd.Err(), p.w)
+func (p *progressPrinter) print(t *template.TemplateExecution) error {
+	total := t.Stats().CmdCount
+	if total == 0 {
+		return nil
+	}
+
+	p.Start(total)
+	for _, cmd := range t.CommandNodesIterator() {
+		p.CommandStarted(cmd.Action, cmd.Entity)
+		p.CommandFinished(cmd.Action, cmd.Entity, cmd.Err())
 	}
 	return nil
 }
 
+// progressBar renders a fixed-width bar such as "▓▓▓▓▓▓▓▓▓▓▓░░░░░░░░░ 4/7 (57%)".
+func progressBar(done, total int) string {
+	const width = 20
+	filled := 0
+	if total > 0 {
+		filled = width * done / total
+	}
+	var bar strings.Builder
+	for i := 0; i < width; i++ {
+		if i < filled {
+			bar.WriteRune('▓')
+		} else {
+			bar.WriteRune('░')
+		}
+	}
+	pct := 0
+	if total > 0 {
+		pct = 100 * done / total
+	}
+	return fmt.Sprintf("%s %d/%d (%d%%)", bar.String(), done, total, pct)
+}
+
+// isTerminal reports whether w is a character device such as a terminal,
+// as opposed to a file or pipe.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// clearPreviousLines moves the cursor up n lines and clears each one, so the
+// next frame can be written in their place.
+func clearPreviousLines(w io.Writer, n int) {
+	for i := 0; i < n; i++ {
+		fmt.Fprint(w, "\x1b[1A\x1b[2K")
+	}
+}
+
 type statLogPrinter struct {
 	w io.Writer
 }
@@ -73,35 +345,64 @@ func (p *shortLogPrinter) print(t *template.TemplateExecution) error {
 }
 
 func newDefaultTemplatePrinter(w io.Writer) logPrinter {
-	return &defaultPrinter{w}
+	return &defaultPrinter{w: w}
 }
 
 type defaultPrinter struct {
-	w io.Writer
+	w     io.Writer
+	hooks []templatelog.Hook
+}
+
+// AddHook registers a templatelog.Hook that receives an Event for every
+// command in the template, alongside the terminal output.
+func (p *defaultPrinter) AddHook(h templatelog.Hook) {
+	p.hooks = append(p.hooks, h)
 }
 
 func (p *defaultPrinter) print(t *template.TemplateExecution) error {
+	tabw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+
+	bus := templatelog.NewBus(append([]templatelog.Hook{&defaultPrinterTerminalHook{tabw}}, p.hooks...)...)
+
 	for _, cmd := range t.CommandNodesIterator() {
-		var status string
-		if cmd.Err() != nil {
-			status = renderRedFn("KO")
-		} else {
-			status = renderGreenFn("OK")
+		event := eventForCommand(t, cmd.Action, cmd.Entity, cmd.Err())
+		event.Fields = map[string]interface{}{"result": cmd.Result()}
+		if err := bus.Emit(event); err != nil {
+			return err
 		}
+	}
+	return tabw.Flush()
+}
 
-		var line string
-		if v, ok := cmd.Result().(string); ok && v != "" {
-			line = fmt.Sprintf("    %s\t%s = %s\t", status, cmd.Entity, v)
-		} else {
-			line = fmt.Sprintf("    %s\t%s %s\t", status, cmd.Action, cmd.Entity)
-		}
+type defaultPrinterTerminalHook struct {
+	w io.Writer
+}
 
-		fmt.Fprintln(p.w, line)
-		writeError(cmd.Err(), p.w)
+func (h *defaultPrinterTerminalHook) Fire(e templatelog.Event) error {
+	var status string
+	if e.Level >= templatelog.Error {
+		status = renderRedFn("KO")
+	} else {
+		status = renderGreenFn("OK")
 	}
+	status = padVisibleWidth(status, 2)
+
+	var line string
+	if v, ok := h.result(e).(string); ok && v != "" {
+		line = fmt.Sprintf("    %s\t%s = %s\t", status, e.Entity, v)
+	} else {
+		line = fmt.Sprintf("    %s\t%s %s\t", status, e.Action, e.Entity)
+	}
+
+	fmt.Fprintln(h.w, line)
+	writeError(e.Err, h.w)
 	return nil
 }
 
+func (h *defaultPrinterTerminalHook) result(e templatelog.Event) interface{} {
+	return e.Fields["result"]
+}
+
 type rawJSONPrinter struct {
 	w io.Writer
 }
@@ -122,6 +423,208 @@ func (p *idOnlyPrinter) print(t *template.TemplateExecution) error {
 	return nil
 }
 
+type junitTestsuite struct {
+	XMLName  xml.Name        `xml:"testsuite"`
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Cases    []junitTestcase `xml:"testcase"`
+}
+
+type junitTestcase struct {
+	Classname string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+type junitXMLPrinter struct {
+	w io.Writer
+}
+
+func (p *junitXMLPrinter) print(t *template.TemplateExecution) error {
+	stats := t.Stats()
+
+	suite := junitTestsuite{
+		Name:     t.ID,
+		Tests:    stats.CmdCount,
+		Failures: stats.KOCount,
+		Errors:   stats.KOCount,
+	}
+
+	for _, cmd := range t.CommandNodesIterator() {
+		tc := junitTestcase{
+			Classname: t.ID,
+			Name:      fmt.Sprintf("%s %s", cmd.Action, cmd.Entity),
+		}
+		if err := cmd.Err(); err != nil {
+			tc.Failure = &junitFailure{Message: err.Error(), Content: err.Error()}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	fmt.Fprint(p.w, xml.Header)
+	enc := xml.NewEncoder(p.w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return fmt.Errorf("junit-xml printer: %s", err)
+	}
+	fmt.Fprintln(p.w)
+	return nil
+}
+
+type codeClimateIssue struct {
+	Type        string                   `json:"type"`
+	CheckName   string                   `json:"check_name"`
+	Description string                   `json:"description"`
+	Severity    string                   `json:"severity"`
+	Location    codeClimateIssueLocation `json:"location"`
+}
+
+type codeClimateIssueLocation struct {
+	Path  string `json:"path"`
+	Lines struct {
+		Begin int `json:"begin"`
+	} `json:"lines"`
+}
+
+type codeClimatePrinter struct {
+	w io.Writer
+}
+
+func (p *codeClimatePrinter) print(t *template.TemplateExecution) error {
+	issues := []codeClimateIssue{}
+
+	for i, cmd := range t.CommandNodesIterator() {
+		if err := cmd.Err(); err != nil {
+			issue := codeClimateIssue{
+				Type:        "issue",
+				CheckName:   fmt.Sprintf("%s %s", cmd.Action, cmd.Entity),
+				Description: err.Error(),
+				Severity:    "critical",
+			}
+			issue.Location.Path = t.ID
+			issue.Location.Lines.Begin = i + 1
+			issues = append(issues, issue)
+		}
+	}
+
+	if err := json.NewEncoder(p.w).Encode(issues); err != nil {
+		return fmt.Errorf("codeclimate printer: %s", err)
+	}
+	return nil
+}
+
+func newTreePrinter(w io.Writer) logPrinter {
+	return &treePrinter{w: w}
+}
+
+// treePrinter renders a TemplateExecution as an indented tree, grouping
+// commands first by entity then by action, similar to a routing table view.
+type treePrinter struct {
+	w io.Writer
+}
+
+func (p *treePrinter) print(t *template.TemplateExecution) error {
+	fmt.Fprintln(p.w, renderYellowFn(t.ID))
+
+	tree := buildTemplateTree(t)
+	entities := sortedStringKeys(tree)
+	for i, entity := range entities {
+		printTreeEntity(p.w, entity, tree[entity].(map[string]interface{}), i == len(entities)-1, "")
+	}
+	return nil
+}
+
+func printTreeEntity(w io.Writer, entity string, actions map[string]interface{}, last bool, prefix string) {
+	fmt.Fprintf(w, "%s%s%s\n", prefix, treeBranch(last), entity)
+
+	childPrefix := prefix + treeIndent(last)
+	actionNames := sortedStringKeys(actions)
+	for i, action := range actionNames {
+		leaves := actions[action].([]map[string]interface{})
+		printTreeAction(w, action, leaves, i == len(actionNames)-1, childPrefix)
+	}
+}
+
+func printTreeAction(w io.Writer, action string, leaves []map[string]interface{}, last bool, prefix string) {
+	fmt.Fprintf(w, "%s%s%s\n", prefix, treeBranch(last), action)
+
+	childPrefix := prefix + treeIndent(last)
+	for i, leaf := range leaves {
+		status := renderGreenFn("OK")
+		if leaf["error"] != nil {
+			status = renderRedFn("KO")
+		}
+		line := fmt.Sprintf("%v %s", leaf["line"], status)
+		if v, ok := leaf["result"].(string); ok && v != "" {
+			line = fmt.Sprintf("%s = %s %s", leaf["line"], v, status)
+		}
+		fmt.Fprintf(w, "%s%s%s\n", childPrefix, treeBranch(i == len(leaves)-1), line)
+	}
+}
+
+func treeBranch(last bool) string {
+	if last {
+		return "└── "
+	}
+	return "├── "
+}
+
+func treeIndent(last bool) string {
+	if last {
+		return "    "
+	}
+	return "│   "
+}
+
+// buildTemplateTree groups t's commands by entity then action, each leaf
+// describing a single command invocation. PrintTree exposes this structure
+// for programmatic consumption.
+func buildTemplateTree(t *template.TemplateExecution) map[string]interface{} {
+	tree := make(map[string]interface{})
+
+	for _, cmd := range t.CommandNodesIterator() {
+		entityNode, ok := tree[cmd.Entity].(map[string]interface{})
+		if !ok {
+			entityNode = make(map[string]interface{})
+			tree[cmd.Entity] = entityNode
+		}
+
+		leaf := map[string]interface{}{
+			"line":   cmd.String(),
+			"result": cmd.Result(),
+			"error":  cmd.Err(),
+		}
+
+		actions, _ := entityNode[cmd.Action].([]map[string]interface{})
+		entityNode[cmd.Action] = append(actions, leaf)
+	}
+
+	return tree
+}
+
+// PrintTree returns t's commands grouped by entity then action as nested
+// maps, e.g. for building an HTML admin view of past template runs.
+func PrintTree(t *template.TemplateExecution) map[string]interface{} {
+	return buildTemplateTree(t)
+}
+
+func sortedStringKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func writeRichLogHeader(t *template.TemplateExecution, w io.Writer) {
 	stats := t.Stats()
 
@@ -173,6 +676,55 @@ func writeSimpleLogHeader(t *template.TemplateExecution, w io.Writer) {
 	fmt.Fprintln(w)
 }
 
+// eventLevel infers severity from the command's error and the template's
+// revert status: an unrevertible template raises a successful command to
+// Warn and a failed one to Fatal, since there is no automatic rollback to
+// fall back on.
+func eventLevel(err error, revertible bool) templatelog.Level {
+	switch {
+	case err != nil && !revertible:
+		return templatelog.Fatal
+	case err != nil:
+		return templatelog.Error
+	case !revertible:
+		return templatelog.Warn
+	default:
+		return templatelog.Info
+	}
+}
+
+func eventForCommand(t *template.TemplateExecution, action, entity string, err error) templatelog.Event {
+	level := eventLevel(err, template.IsRevertible(t.Template))
+	return templatelog.Event{
+		ID:        t.ID,
+		Action:    action,
+		Entity:    entity,
+		Level:     level,
+		Err:       err,
+		Timestamp: time.Now(),
+	}
+}
+
+var ansiEscapeSeq = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// stripANSI removes color escape sequences, for callers that need the
+// visible width of a string (e.g. column alignment) rather than its byte length.
+func stripANSI(s string) string {
+	return ansiEscapeSeq.ReplaceAllString(s, "")
+}
+
+// padVisibleWidth right-pads s with spaces so its visible (ANSI-stripped)
+// width reaches width. tabwriter still counts the escape bytes of s, so its
+// columns line up only where every cell of a column carries escape codes of
+// the same length, as the colored OK/KO status cells do.
+func padVisibleWidth(s string, width int) string {
+	visible := len(stripANSI(s))
+	if visible >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-visible)
+}
+
 func writeError(err error, w io.Writer) {
 	if err != nil {
 		for _, msg := range formatMultiLineErrMsg(err.Error()) {
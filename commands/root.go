@@ -0,0 +1,9 @@
+package commands
+
+import "github.com/spf13/cobra"
+
+// RootCmd is the base command every awless subcommand attaches to.
+var RootCmd = &cobra.Command{
+	Use:   "awless",
+	Short: "Manage your cloud infrastructure from the command line",
+}
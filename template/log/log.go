@@ -0,0 +1,77 @@
+// Package log provides a structured event bus for template executions,
+// emitted alongside (not instead of) the terminal printers in commands.
+// It lets downstream integrations (logrus, zap, syslog, a webhook, ...)
+// observe command completions without forking the printer code.
+package log
+
+import "time"
+
+// Level is the severity of an Event, inferred from the command's error and
+// revert status.
+type Level int
+
+const (
+	Info Level = iota
+	Warn
+	Error
+	Fatal
+)
+
+func (l Level) String() string {
+	switch l {
+	case Warn:
+		return "warn"
+	case Error:
+		return "error"
+	case Fatal:
+		return "fatal"
+	default:
+		return "info"
+	}
+}
+
+// Event describes the completion of a single command within a template
+// execution.
+type Event struct {
+	ID        string
+	Action    string
+	Entity    string
+	Level     Level
+	Err       error
+	Timestamp time.Time
+	Fields    map[string]interface{}
+}
+
+// Hook receives Events as they are emitted. Implementations should return
+// quickly and are responsible for their own error handling beyond the
+// returned error, which is surfaced to the Bus caller.
+type Hook interface {
+	Fire(Event) error
+}
+
+// Bus fans out Events to every registered Hook.
+type Bus struct {
+	hooks []Hook
+}
+
+// NewBus creates a Bus with the given hooks already registered.
+func NewBus(hooks ...Hook) *Bus {
+	return &Bus{hooks: hooks}
+}
+
+// AddHook registers an additional Hook on the bus.
+func (b *Bus) AddHook(h Hook) {
+	b.hooks = append(b.hooks, h)
+}
+
+// Emit sends the Event to every registered Hook, returning the first error
+// encountered, if any.
+func (b *Bus) Emit(e Event) error {
+	var first error
+	for _, h := range b.hooks {
+		if err := h.Fire(e); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}